@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// authConfig is derived from the AUTH_* environment variables. Auth is
+// optional: when Issuer or Audience is unset the server falls back to the
+// static WINGMAN_TOKEN/OPENAI_API_KEY model.
+type authConfig struct {
+	enabled bool
+
+	issuer   string
+	audience string
+	claim    string
+	mode     string // "passthrough" or "exchange"
+}
+
+func loadAuthConfig() authConfig {
+	issuer := os.Getenv("AUTH_ISSUER")
+	audience := os.Getenv("AUTH_AUDIENCE")
+
+	if issuer == "" || audience == "" {
+		return authConfig{}
+	}
+
+	claim := os.Getenv("AUTH_CLAIM")
+
+	if claim == "" {
+		claim = "preferred_username"
+	}
+
+	mode := os.Getenv("AUTH_MODE")
+
+	if mode != "passthrough" {
+		mode = "exchange"
+	}
+
+	return authConfig{
+		enabled:  true,
+		issuer:   issuer,
+		audience: audience,
+		claim:    claim,
+		mode:     mode,
+	}
+}
+
+type claimsContextKey struct{}
+
+// claimsFromContext returns the validated claims attached by authMiddleware,
+// if any.
+func claimsFromContext(ctx context.Context) (map[string]any, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(map[string]any)
+
+	return claims, ok
+}
+
+// jwksCache fetches and caches the issuer's signing keys, refreshing them
+// once the cache expires or an unknown kid is encountered.
+type jwksCache struct {
+	issuer string
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+const jwksCacheTTL = 10 * time.Minute
+
+func newJWKSCache(issuer string) *jwksCache {
+	return &jwksCache{issuer: issuer, keys: map[string]*rsa.PublicKey{}}
+}
+
+func (c *jwksCache) key(kid string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	stale := time.Since(c.fetched) > jwksCacheTTL
+	key, ok := c.keys[kid]
+	c.mu.Unlock()
+
+	if ok && !stale {
+		return key, nil
+	}
+
+	if err := c.refresh(); err != nil {
+		if ok {
+			return key, nil
+		}
+
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key, ok = c.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	return key, nil
+}
+
+func (c *jwksCache) refresh() error {
+	discovery, err := httpGetJSON(strings.TrimRight(c.issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return err
+	}
+
+	jwksURI, _ := discovery["jwks_uri"].(string)
+	if jwksURI == "" {
+		return errors.New("OIDC discovery document is missing jwks_uri")
+	}
+
+	var doc struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+
+	if err := httpGetDecode(jwksURI, &doc); err != nil {
+		return err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(doc.Keys))
+
+	for _, k := range doc.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+
+		pub, err := rsaPublicKeyFromJWK(k.N, k.E)
+		if err != nil {
+			continue
+		}
+
+		keys[k.Kid] = pub
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.fetched = time.Now()
+	c.mu.Unlock()
+
+	return nil
+}
+
+func rsaPublicKeyFromJWK(nEnc, eEnc string) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(nEnc)
+	if err != nil {
+		return nil, err
+	}
+
+	eBytes, err := base64.RawURLEncoding.DecodeString(eEnc)
+	if err != nil {
+		return nil, err
+	}
+
+	n := new(big.Int).SetBytes(nBytes)
+	e := new(big.Int).SetBytes(eBytes)
+
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func httpGetJSON(url string) (map[string]any, error) {
+	var out map[string]any
+
+	if err := httpGetDecode(url, &out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func httpGetDecode(url string, out any) error {
+	client := http.Client{Timeout: 5 * time.Second}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// verifyToken validates a compact RS256 JWT's signature, issuer, audience
+// and expiry, returning its claims.
+func verifyToken(cfg authConfig, jwks *jwksCache, token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, err
+	}
+
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", header.Alg)
+	}
+
+	key, err := jwks.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+
+	hashed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], sig); err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+
+	payloadJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+
+	if err := json.Unmarshal(payloadJSON, &claims); err != nil {
+		return nil, err
+	}
+
+	if iss, _ := claims["iss"].(string); iss != cfg.issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", iss)
+	}
+
+	if !audienceMatches(claims["aud"], cfg.audience) {
+		return nil, errors.New("token audience does not match")
+	}
+
+	if exp, ok := claims["exp"].(float64); ok && time.Now().After(time.Unix(int64(exp), 0)) {
+		return nil, errors.New("token has expired")
+	}
+
+	return claims, nil
+}
+
+func audienceMatches(aud any, expected string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == expected
+	case []any:
+		for _, a := range v {
+			if s, _ := a.(string); s == expected {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// authMiddleware validates the incoming bearer token and, in "exchange"
+// mode, swaps it for the server's platform token before the request reaches
+// the reverse proxy. In "passthrough" mode the original token is left
+// untouched so it is forwarded upstream as-is.
+func authMiddleware(cfg authConfig, jwks *jwksCache, platformToken string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authz := r.Header.Get("Authorization")
+		token, ok := strings.CutPrefix(authz, "Bearer ")
+
+		if !ok || token == "" {
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := verifyToken(cfg, jwks, token)
+		if err != nil {
+			http.Error(w, "invalid token: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), claimsContextKey{}, claims)
+		r = r.WithContext(ctx)
+
+		if cfg.mode == "exchange" && platformToken != "" {
+			r.Header.Set("Authorization", "Bearer "+platformToken)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func registerWhoamiRoute(mux *http.ServeMux, cfg authConfig, jwks *jwksCache, platformToken string) {
+	whoami := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := claimsFromContext(r.Context())
+		if !ok {
+			http.Error(w, "not authenticated", http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(claims)
+	})
+
+	mux.Handle("GET /api/v1/whoami", authMiddleware(cfg, jwks, platformToken, whoami))
+}
@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestAudienceMatches(t *testing.T) {
+	cases := []struct {
+		aud      any
+		expected string
+		want     bool
+	}{
+		{"wingman", "wingman", true},
+		{"other", "wingman", false},
+		{[]any{"a", "wingman"}, "wingman", true},
+		{[]any{"a", "b"}, "wingman", false},
+		{nil, "wingman", false},
+	}
+
+	for _, c := range cases {
+		if got := audienceMatches(c.aud, c.expected); got != c.want {
+			t.Errorf("audienceMatches(%v, %q) = %v, want %v", c.aud, c.expected, got, c.want)
+		}
+	}
+}
+
+func TestLoadAuthConfigRequiresIssuerAndAudience(t *testing.T) {
+	t.Setenv("AUTH_ISSUER", "")
+	t.Setenv("AUTH_AUDIENCE", "")
+
+	if cfg := loadAuthConfig(); cfg.enabled {
+		t.Fatal("expected auth to be disabled without issuer/audience")
+	}
+
+	t.Setenv("AUTH_ISSUER", "https://issuer.example")
+	t.Setenv("AUTH_AUDIENCE", "wingman")
+
+	cfg := loadAuthConfig()
+
+	if !cfg.enabled {
+		t.Fatal("expected auth to be enabled with issuer and audience set")
+	}
+
+	if cfg.mode != "exchange" {
+		t.Errorf("mode = %q, want default %q", cfg.mode, "exchange")
+	}
+}
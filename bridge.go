@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Tool describes a single named capability loaded from tools.yaml. Schema is
+// a JSON-schema fragment describing the expected arguments, and Backend
+// selects how an invocation is dispatched.
+type Tool struct {
+	Name        string         `yaml:"name"`
+	Description string         `yaml:"description,omitempty"`
+	Schema      map[string]any `yaml:"schema,omitempty"`
+
+	Backend  string            `yaml:"backend"`
+	Endpoint string            `yaml:"endpoint,omitempty"`
+	Command  string            `yaml:"command,omitempty"`
+	Args     []string          `yaml:"args,omitempty"`
+	Headers  map[string]string `yaml:"headers,omitempty"`
+
+	Allow []string `yaml:"allow,omitempty"`
+	Deny  []string `yaml:"deny,omitempty"`
+}
+
+// toolSchema is the shape exposed to clients and injectable into chat
+// completions as a function/tool definition.
+type toolSchema struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Schema      map[string]any `json:"schema,omitempty"`
+}
+
+// invokeResult is the normalized envelope returned by every backend.
+type invokeResult struct {
+	Result any      `json:"result,omitempty"`
+	Error  string   `json:"error,omitempty"`
+	Logs   []string `json:"logs,omitempty"`
+}
+
+func loadTools() ([]Tool, error) {
+	data, err := os.ReadFile("tools.yaml")
+	if err != nil {
+		return nil, err
+	}
+
+	var tools []Tool
+
+	if err := yaml.Unmarshal(data, &tools); err != nil {
+		return nil, err
+	}
+
+	return tools, nil
+}
+
+func findTool(tools []Tool, name string) (Tool, bool) {
+	for _, tool := range tools {
+		if tool.Name == name {
+			return tool, true
+		}
+	}
+
+	return Tool{}, false
+}
+
+// toolCaller identifies who is invoking a tool for the allow/deny check.
+// When auth is enabled the caller comes only from the verified claim set by
+// authMiddleware (falling back to the remote address if that claim is
+// absent) — a client can never override this with a header. Without auth
+// configured there is no verified identity at all, so it falls back to the
+// client-supplied X-Wingman-User header (or remote address); that fallback
+// is trust-on-the-wire and only makes sense behind a trusted network
+// boundary, not as a standalone access control.
+func toolCaller(r *http.Request, authEnabled bool, claim string) string {
+	if authEnabled {
+		if claim != "" {
+			if claims, ok := claimsFromContext(r.Context()); ok {
+				if v, _ := claims[claim].(string); v != "" {
+					return v
+				}
+			}
+		}
+
+		return r.RemoteAddr
+	}
+
+	if caller := r.Header.Get("X-Wingman-User"); caller != "" {
+		return caller
+	}
+
+	return r.RemoteAddr
+}
+
+func toolAllowed(tool Tool, caller string) bool {
+	for _, denied := range tool.Deny {
+		if denied == caller {
+			return false
+		}
+	}
+
+	if len(tool.Allow) == 0 {
+		return true
+	}
+
+	for _, allowed := range tool.Allow {
+		if allowed == caller {
+			return true
+		}
+	}
+
+	return false
+}
+
+// registerToolRoutes wires the tool bridge endpoints into mux when a
+// tools.yaml manifest is present. When auth is configured, invocation is
+// wrapped with authMiddleware so toolCaller sees a verified identity rather
+// than a client-supplied header.
+func registerToolRoutes(mux *http.ServeMux, authCfg authConfig, jwks *jwksCache, platformToken string) bool {
+	if _, err := os.Stat("tools.yaml"); err != nil {
+		return false
+	}
+
+	mux.HandleFunc("GET /api/v1/tools", func(w http.ResponseWriter, r *http.Request) {
+		tools, err := loadTools()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		schemas := make([]toolSchema, 0, len(tools))
+
+		for _, tool := range tools {
+			schemas = append(schemas, toolSchema{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Schema:      tool.Schema,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(schemas)
+	})
+
+	invoke := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+
+		tools, err := loadTools()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		tool, ok := findTool(tools, name)
+		if !ok {
+			http.Error(w, "tool not found", http.StatusNotFound)
+			return
+		}
+
+		if !toolAllowed(tool, toolCaller(r, authCfg.enabled, authCfg.claim)) {
+			http.Error(w, "tool not allowed", http.StatusForbidden)
+			return
+		}
+
+		var args map[string]any
+
+		if r.Body != nil {
+			if err := json.NewDecoder(r.Body).Decode(&args); err != nil && err.Error() != "EOF" {
+				http.Error(w, "invalid arguments: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if err := validateToolArgs(tool.Schema, args); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(invokeResult{Error: err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+
+		result := invokeTool(ctx, tool, args)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if result.Error != "" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+
+		json.NewEncoder(w).Encode(result)
+	})
+
+	if authCfg.enabled {
+		mux.Handle("POST /api/v1/tools/{name}/invoke", authMiddleware(authCfg, jwks, platformToken, invoke))
+	} else {
+		mux.Handle("POST /api/v1/tools/{name}/invoke", invoke)
+	}
+
+	return true
+}
+
+// validateToolArgs checks args against a minimal subset of JSON schema:
+// object type, required fields and basic property types.
+func validateToolArgs(schema map[string]any, args map[string]any) error {
+	if schema == nil {
+		return nil
+	}
+
+	required, _ := schema["required"].([]any)
+
+	for _, field := range required {
+		name, _ := field.(string)
+
+		if name == "" {
+			continue
+		}
+
+		if _, ok := args[name]; !ok {
+			return fmt.Errorf("missing required argument %q", name)
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+
+	for name, value := range args {
+		prop, ok := properties[name].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		want, _ := prop["type"].(string)
+
+		if want != "" && !jsonTypeMatches(want, value) {
+			return fmt.Errorf("argument %q must be of type %s", name, want)
+		}
+	}
+
+	return nil
+}
+
+func jsonTypeMatches(want string, value any) bool {
+	switch want {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number", "integer":
+		_, ok := value.(float64)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	default:
+		return true
+	}
+}
+
+func invokeTool(ctx context.Context, tool Tool, args map[string]any) invokeResult {
+	switch tool.Backend {
+	case "http":
+		return invokeHTTPTool(ctx, tool, args)
+	case "command":
+		return invokeCommandTool(ctx, tool, args)
+	case "mcp":
+		return invokeMCPTool(ctx, tool, args)
+	default:
+		return invokeResult{Error: fmt.Sprintf("unknown backend %q", tool.Backend)}
+	}
+}
+
+func invokeHTTPTool(ctx context.Context, tool Tool, args map[string]any) invokeResult {
+	body, err := json.Marshal(args)
+	if err != nil {
+		return invokeResult{Error: err.Error()}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tool.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return invokeResult{Error: err.Error()}
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for key, value := range tool.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return invokeResult{Error: err.Error()}
+	}
+
+	defer resp.Body.Close()
+
+	var result any
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return invokeResult{Error: "decoding response: " + err.Error()}
+	}
+
+	if resp.StatusCode >= 400 {
+		return invokeResult{Error: fmt.Sprintf("upstream returned %d", resp.StatusCode), Result: result}
+	}
+
+	return invokeResult{Result: result}
+}
+
+func invokeCommandTool(ctx context.Context, tool Tool, args map[string]any) invokeResult {
+	if tool.Command == "" {
+		return invokeResult{Error: "command backend requires a command"}
+	}
+
+	expanded := make([]string, len(tool.Args))
+
+	for i, arg := range tool.Args {
+		expanded[i] = expandToolArg(arg, args)
+	}
+
+	cmd := exec.CommandContext(ctx, tool.Command, expanded...)
+
+	var stdout, stderr bytes.Buffer
+
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+
+	logs := splitLogLines(stderr.String())
+
+	if err != nil {
+		return invokeResult{Error: err.Error(), Logs: logs}
+	}
+
+	return invokeResult{Result: strings.TrimRight(stdout.String(), "\n"), Logs: logs}
+}
+
+func invokeMCPTool(ctx context.Context, tool Tool, args map[string]any) invokeResult {
+	payload := map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params": map[string]any{
+			"name":      tool.Name,
+			"arguments": args,
+		},
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return invokeResult{Error: err.Error()}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tool.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return invokeResult{Error: err.Error()}
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for key, value := range tool.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return invokeResult{Error: err.Error()}
+	}
+
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Result any `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return invokeResult{Error: "decoding response: " + err.Error()}
+	}
+
+	if envelope.Error != nil {
+		return invokeResult{Error: envelope.Error.Message}
+	}
+
+	return invokeResult{Result: envelope.Result}
+}
+
+func expandToolArg(arg string, args map[string]any) string {
+	for name, value := range args {
+		arg = strings.ReplaceAll(arg, "{{"+name+"}}", fmt.Sprintf("%v", value))
+	}
+
+	return arg
+}
+
+func splitLogLines(s string) []string {
+	s = strings.TrimRight(s, "\n")
+
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, "\n")
+}
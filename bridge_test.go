@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestToolAllowed(t *testing.T) {
+	tool := Tool{Allow: []string{"alice"}, Deny: []string{"mallory"}}
+
+	if !toolAllowed(tool, "alice") {
+		t.Error("expected allow-listed caller to be allowed")
+	}
+
+	if toolAllowed(tool, "bob") {
+		t.Error("expected caller missing from a non-empty allow list to be denied")
+	}
+
+	if toolAllowed(Tool{}, "mallory") == false {
+		t.Error("expected no allow/deny lists to permit everyone")
+	}
+
+	if toolAllowed(tool, "mallory") {
+		t.Error("expected deny list to take precedence")
+	}
+}
+
+func TestToolCallerPrefersVerifiedClaim(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/tools/demo/invoke", nil)
+	req.Header.Set("X-Wingman-User", "spoofed")
+
+	if got := toolCaller(req, false, "preferred_username"); got != "spoofed" {
+		t.Errorf("with auth disabled and no claims, expected header fallback %q, got %q", "spoofed", got)
+	}
+
+	ctx := context.WithValue(req.Context(), claimsContextKey{}, map[string]any{"preferred_username": "alice"})
+	req = req.WithContext(ctx)
+
+	if got := toolCaller(req, true, "preferred_username"); got != "alice" {
+		t.Errorf("expected verified claim to win over X-Wingman-User, got %q", got)
+	}
+}
+
+func TestToolCallerNeverTrustsHeaderWhenAuthEnabled(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/tools/demo/invoke", nil)
+	req.RemoteAddr = "203.0.113.1:1234"
+	req.Header.Set("X-Wingman-User", "spoofed")
+
+	// Authenticated, but the configured claim is absent from the token:
+	// must fall back to the remote address, never the spoofable header.
+	ctx := context.WithValue(req.Context(), claimsContextKey{}, map[string]any{"sub": "alice"})
+	req = req.WithContext(ctx)
+
+	if got := toolCaller(req, true, "preferred_username"); got != req.RemoteAddr {
+		t.Errorf("expected remote-addr fallback %q when claim is missing, got %q", req.RemoteAddr, got)
+	}
+
+	// Authenticated with no claims attached at all (shouldn't normally
+	// happen once authMiddleware has run, but must still not trust the
+	// header).
+	bare, _ := http.NewRequest(http.MethodPost, "/api/v1/tools/demo/invoke", nil)
+	bare.RemoteAddr = "203.0.113.2:1234"
+	bare.Header.Set("X-Wingman-User", "spoofed")
+
+	if got := toolCaller(bare, true, "preferred_username"); got != bare.RemoteAddr {
+		t.Errorf("expected remote-addr fallback %q with no claims, got %q", bare.RemoteAddr, got)
+	}
+}
+
+func TestValidateToolArgsRequiredFields(t *testing.T) {
+	schema := map[string]any{
+		"required": []any{"city"},
+		"properties": map[string]any{
+			"city": map[string]any{"type": "string"},
+		},
+	}
+
+	if err := validateToolArgs(schema, map[string]any{}); err == nil {
+		t.Error("expected missing required field to fail validation")
+	}
+
+	if err := validateToolArgs(schema, map[string]any{"city": "Berlin"}); err != nil {
+		t.Errorf("expected valid args to pass, got %v", err)
+	}
+}
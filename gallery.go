@@ -0,0 +1,315 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// galleryModel is a single catalog entry served by a remote gallery.
+type galleryModel struct {
+	ID string `json:"id,omitempty" yaml:"id,omitempty"`
+
+	Name        string `json:"name,omitempty" yaml:"name,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+
+	Prompts []string `json:"prompts,omitempty" yaml:"prompts,omitempty"`
+
+	URL string `json:"url,omitempty" yaml:"url,omitempty"`
+}
+
+// jobStatus tracks the lifecycle of a gallery apply job.
+type jobStatus string
+
+const (
+	jobPending jobStatus = "pending"
+	jobRunning jobStatus = "running"
+	jobDone    jobStatus = "done"
+	jobFailed  jobStatus = "failed"
+)
+
+type applyJob struct {
+	ID      string    `json:"id"`
+	ModelID string    `json:"modelId"`
+	Status  jobStatus `json:"status"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// galleryManager owns the galleries configured via GALLERY_URLS, the
+// in-memory job queue, and the models.yaml file it writes apply results to.
+type galleryManager struct {
+	urls []string
+
+	mu   sync.Mutex
+	jobs map[string]*applyJob
+
+	queue chan *applyJob
+}
+
+func newGalleryManager(urls []string) *galleryManager {
+	g := &galleryManager{
+		urls:  urls,
+		jobs:  map[string]*applyJob{},
+		queue: make(chan *applyJob, 16),
+	}
+
+	go g.worker()
+
+	return g
+}
+
+func (g *galleryManager) worker() {
+	for job := range g.queue {
+		g.runJob(job)
+	}
+}
+
+func (g *galleryManager) runJob(job *applyJob) {
+	g.mu.Lock()
+	job.Status = jobRunning
+	g.mu.Unlock()
+
+	model, err := g.findModel(job.ModelID)
+
+	if err == nil {
+		err = applyModel(model)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err != nil {
+		job.Status = jobFailed
+		job.Error = err.Error()
+		return
+	}
+
+	job.Status = jobDone
+}
+
+func (g *galleryManager) listModels() ([]galleryModel, error) {
+	var models []galleryModel
+
+	for _, u := range g.urls {
+		catalog, err := fetchCatalog(u)
+		if err != nil {
+			return nil, fmt.Errorf("fetching gallery %s: %w", u, err)
+		}
+
+		models = append(models, catalog...)
+	}
+
+	return models, nil
+}
+
+func (g *galleryManager) findModel(id string) (galleryModel, error) {
+	models, err := g.listModels()
+	if err != nil {
+		return galleryModel{}, err
+	}
+
+	for _, model := range models {
+		if model.ID == id {
+			return model, nil
+		}
+	}
+
+	return galleryModel{}, fmt.Errorf("model %q not found in any gallery", id)
+}
+
+func (g *galleryManager) submit(modelID string) (*applyJob, error) {
+	job := &applyJob{
+		ID:      newJobID(),
+		ModelID: modelID,
+		Status:  jobPending,
+	}
+
+	g.mu.Lock()
+	g.jobs[job.ID] = job
+	g.mu.Unlock()
+
+	select {
+	case g.queue <- job:
+	default:
+		return nil, fmt.Errorf("gallery apply queue is full")
+	}
+
+	return job, nil
+}
+
+func (g *galleryManager) get(id string) (*applyJob, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	job, ok := g.jobs[id]
+
+	return job, ok
+}
+
+func fetchCatalog(source string) ([]galleryModel, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		resp, err := http.Get(source)
+		if err != nil {
+			return nil, err
+		}
+
+		defer resp.Body.Close()
+
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		var models []galleryModel
+
+		if err := yaml.Unmarshal(data, &models); err != nil {
+			return nil, err
+		}
+
+		return models, nil
+	}
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var models []galleryModel
+
+	if err := yaml.Unmarshal(data, &models); err != nil {
+		return nil, err
+	}
+
+	return models, nil
+}
+
+// modelsMu serializes apply jobs' read-modify-write of the active
+// models.yaml. The /config.json handler does not take it; applyModel's
+// write-then-rename keeps that reader from seeing a partially written file.
+var modelsMu sync.Mutex
+
+// applyModel atomically appends (or replaces) a gallery model in the active
+// models.yaml.
+func applyModel(model galleryModel) error {
+	modelsMu.Lock()
+	defer modelsMu.Unlock()
+
+	var models []galleryModel
+
+	if data, err := os.ReadFile("models.yaml"); err == nil {
+		if err := yaml.Unmarshal(data, &models); err != nil {
+			return err
+		}
+	}
+
+	replaced := false
+
+	for i, existing := range models {
+		if existing.ID == model.ID {
+			models[i] = model
+			replaced = true
+			break
+		}
+	}
+
+	if !replaced {
+		models = append(models, model)
+	}
+
+	data, err := yaml.Marshal(models)
+	if err != nil {
+		return err
+	}
+
+	tmp := "models.yaml.tmp"
+
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, "models.yaml")
+}
+
+// newJobID returns a random (version 4) UUID for an apply job.
+func newJobID() string {
+	var b [16]byte
+
+	rand.Read(b[:])
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func registerGalleryRoutes(mux *http.ServeMux, g *galleryManager) {
+	mux.HandleFunc("GET /api/v1/models/gallery", func(w http.ResponseWriter, r *http.Request) {
+		models, err := g.listModels()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models)
+	})
+
+	mux.HandleFunc("POST /api/v1/models/apply", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ID string `json:"id"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.ID == "" {
+			http.Error(w, "id is required", http.StatusBadRequest)
+			return
+		}
+
+		job, err := g.submit(body.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+	})
+
+	mux.HandleFunc("GET /api/v1/models/jobs/{uuid}", func(w http.ResponseWriter, r *http.Request) {
+		job, ok := g.get(r.PathValue("uuid"))
+		if !ok {
+			http.Error(w, "job not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job)
+	})
+}
+
+func galleryURLs() []string {
+	val := os.Getenv("GALLERY_URLS")
+
+	if val == "" {
+		return nil
+	}
+
+	var urls []string
+
+	for _, u := range strings.Split(val, ",") {
+		u = strings.TrimSpace(u)
+
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+
+	return urls
+}
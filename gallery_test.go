@@ -0,0 +1,148 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNewJobID(t *testing.T) {
+	seen := map[string]bool{}
+
+	for i := 0; i < 100; i++ {
+		id := newJobID()
+
+		if len(id) != 36 {
+			t.Fatalf("newJobID() = %q, want length 36", id)
+		}
+
+		if id[14] != '4' {
+			t.Errorf("newJobID() = %q, want version nibble 4 at index 14", id)
+		}
+
+		if seen[id] {
+			t.Fatalf("newJobID() returned duplicate id %q", id)
+		}
+
+		seen[id] = true
+	}
+}
+
+func withTempDir(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd: %v", err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Chdir: %v", err)
+	}
+
+	t.Cleanup(func() {
+		os.Chdir(cwd)
+	})
+}
+
+func TestApplyModelAppendsAndReplaces(t *testing.T) {
+	withTempDir(t)
+
+	if err := applyModel(galleryModel{ID: "a", Name: "Alpha"}); err != nil {
+		t.Fatalf("applyModel: %v", err)
+	}
+
+	models := readModelsYAML(t)
+
+	if len(models) != 1 || models[0].Name != "Alpha" {
+		t.Fatalf("after first apply, models = %+v", models)
+	}
+
+	if err := applyModel(galleryModel{ID: "b", Name: "Beta"}); err != nil {
+		t.Fatalf("applyModel: %v", err)
+	}
+
+	models = readModelsYAML(t)
+
+	if len(models) != 2 {
+		t.Fatalf("after append, models = %+v, want 2 entries", models)
+	}
+
+	if err := applyModel(galleryModel{ID: "a", Name: "Alpha v2"}); err != nil {
+		t.Fatalf("applyModel: %v", err)
+	}
+
+	models = readModelsYAML(t)
+
+	if len(models) != 2 {
+		t.Fatalf("after replace, models = %+v, want 2 entries", models)
+	}
+
+	if models[0].ID != "a" || models[0].Name != "Alpha v2" {
+		t.Errorf("expected id %q to be replaced in place, got %+v", "a", models[0])
+	}
+}
+
+func readModelsYAML(t *testing.T) []galleryModel {
+	t.Helper()
+
+	models, err := fetchCatalog("models.yaml")
+	if err != nil {
+		t.Fatalf("fetchCatalog: %v", err)
+	}
+
+	return models
+}
+
+func TestGalleryManagerSubmitAndGetLifecycle(t *testing.T) {
+	withTempDir(t)
+
+	if err := os.WriteFile("catalog.yaml", []byte(`
+- id: a
+  name: Alpha
+  url: http://example.invalid/a
+`), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	g := newGalleryManager([]string{"catalog.yaml"})
+
+	job, err := g.submit("a")
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for {
+		got, ok := g.get(job.ID)
+		if !ok {
+			t.Fatalf("get(%q) = not found", job.ID)
+		}
+
+		if got.Status == jobDone || got.Status == jobFailed {
+			if got.Status != jobDone {
+				t.Fatalf("job finished with status %q, error %q", got.Status, got.Error)
+			}
+
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("job %q did not finish in time, last status %q", job.ID, got.Status)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	models := readModelsYAML(t)
+
+	if len(models) != 1 || models[0].ID != "a" {
+		t.Fatalf("expected applied model %q in models.yaml, got %+v", "a", models)
+	}
+
+	if _, ok := g.get("does-not-exist"); ok {
+		t.Error("get() of unknown job id should report not found")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// upstreamStatus reports the result of probing a single upstream.
+type upstreamStatus struct {
+	OK        bool   `json:"ok"`
+	LatencyMs int64  `json:"latencyMs,omitempty"`
+	Status    string `json:"status,omitempty"`
+}
+
+type featureStatus struct {
+	TTS    bool `json:"tts,omitempty"`
+	STT    bool `json:"stt,omitempty"`
+	Voice  bool `json:"voice,omitempty"`
+	Vision bool `json:"vision,omitempty"`
+	Image  bool `json:"image,omitempty"`
+}
+
+type statusResponse struct {
+	Platform upstreamStatus  `json:"platform"`
+	Realtime *upstreamStatus `json:"realtime,omitempty"`
+	Features featureStatus   `json:"features"`
+}
+
+// upstreamProbe caches the result of probing an upstream's /v1/models for up
+// to probeCacheTTL so /readyz doesn't hammer the platform on every check.
+type upstreamProbe struct {
+	url   *url.URL
+	token string
+
+	mu      sync.Mutex
+	cached  upstreamStatus
+	checked time.Time
+}
+
+const probeCacheTTL = 5 * time.Second
+
+func newUpstreamProbe(u *url.URL, token string) *upstreamProbe {
+	if u == nil {
+		return nil
+	}
+
+	return &upstreamProbe{url: u, token: token}
+}
+
+func (p *upstreamProbe) check() upstreamStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if time.Since(p.checked) < probeCacheTTL {
+		return p.cached
+	}
+
+	start := time.Now()
+
+	req, err := http.NewRequest(http.MethodGet, p.url.String()+"/v1/models", nil)
+	if err != nil {
+		p.cached = upstreamStatus{OK: false, Status: err.Error()}
+		p.checked = time.Now()
+		return p.cached
+	}
+
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	client := http.Client{Timeout: 3 * time.Second}
+
+	resp, err := client.Do(req)
+	latency := time.Since(start).Milliseconds()
+
+	if err != nil {
+		p.cached = upstreamStatus{OK: false, LatencyMs: latency, Status: err.Error()}
+		p.checked = time.Now()
+		return p.cached
+	}
+
+	defer resp.Body.Close()
+
+	p.cached = upstreamStatus{
+		OK:        resp.StatusCode < 400,
+		LatencyMs: latency,
+		Status:    resp.Status,
+	}
+	p.checked = time.Now()
+
+	return p.cached
+}
+
+func registerHealthRoutes(mux *http.ServeMux, platform, realtime *upstreamProbe, features featureStatus) {
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+
+	buildStatus := func() (statusResponse, bool) {
+		resp := statusResponse{
+			Platform: platform.check(),
+			Features: features,
+		}
+
+		ready := resp.Platform.OK
+
+		if realtime != nil {
+			rt := realtime.check()
+			resp.Realtime = &rt
+			ready = ready && rt.OK
+		}
+
+		return resp, ready
+	}
+
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		resp, ready := buildStatus()
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	mux.HandleFunc("GET /api/v1/status", func(w http.ResponseWriter, r *http.Request) {
+		resp, _ := buildStatus()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+}
@@ -42,6 +42,10 @@ func main() {
 	repositoryEmbedder := os.Getenv("REPOSITORY_EMBEDDER")
 	repositoryExtractor := os.Getenv("REPOSITORY_EXTRACTOR")
 
+	authCfg := loadAuthConfig()
+
+	mediaStore, mediaEnabled := newMediaStore()
+
 	mux := http.NewServeMux()
 	dist := os.DirFS("dist")
 
@@ -85,10 +89,23 @@ func main() {
 			URL string `json:"url,omitempty" yaml:"url,omitempty"`
 		}
 
+		type toolsType struct {
+			Enabled  bool   `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+			Endpoint string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+		}
+
 		type artifactsType struct {
 			Enabled bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
 		}
 
+		type authType struct {
+			Enabled bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+		}
+
+		type mediaType struct {
+			Enabled bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+		}
+
 		type repositoryType struct {
 			Enabled   bool   `json:"enabled,omitempty" yaml:"enabled,omitempty"`
 			Embedder  string `json:"embedder,omitempty" yaml:"embedder,omitempty"`
@@ -122,8 +139,11 @@ func main() {
 			Internet *internetType `json:"internet,omitempty" yaml:"internet,omitempty"`
 
 			Bridge *bridgeType `json:"bridge,omitempty" yaml:"bridge,omitempty"`
+			Tools  *toolsType  `json:"tools,omitempty" yaml:"tools,omitempty"`
 
 			Artifacts  *artifactsType  `json:"artifacts,omitempty" yaml:"artifacts,omitempty"`
+			Auth       *authType       `json:"auth,omitempty" yaml:"auth,omitempty"`
+			Media      *mediaType      `json:"media,omitempty" yaml:"media,omitempty"`
 			Repository *repositoryType `json:"repository,omitempty" yaml:"repository,omitempty"`
 			Translator *translatorType `json:"translator,omitempty" yaml:"translator,omitempty"`
 
@@ -190,12 +210,31 @@ func main() {
 			}
 		}
 
+		if _, err := os.Stat("tools.yaml"); err == nil {
+			config.Tools = &toolsType{
+				Enabled:  true,
+				Endpoint: "/api/v1/tools",
+			}
+		}
+
 		if artifacts {
 			config.Artifacts = &artifactsType{
 				Enabled: true,
 			}
 		}
 
+		if authCfg.enabled {
+			config.Auth = &authType{
+				Enabled: true,
+			}
+		}
+
+		if mediaEnabled {
+			config.Media = &mediaType{
+				Enabled: true,
+			}
+		}
+
 		if repository {
 			config.Repository = &repositoryType{
 				Enabled:   true,
@@ -236,29 +275,68 @@ func main() {
 		json.NewEncoder(w).Encode(manifest)
 	})
 
+	registerHealthRoutes(mux, newUpstreamProbe(platformURL, token), newUpstreamProbe(realtimeURL, token), featureStatus{
+		TTS:    tts,
+		STT:    stt,
+		Voice:  voice,
+		Vision: vision,
+		Image:  image,
+	})
+
+	registerMediaRoutes(mux, mediaStore, mediaEnabled)
+
+	if urls := galleryURLs(); len(urls) > 0 {
+		registerGalleryRoutes(mux, newGalleryManager(urls))
+	}
+
+	var jwks *jwksCache
+
+	if authCfg.enabled {
+		jwks = newJWKSCache(authCfg.issuer)
+		registerWhoamiRoute(mux, authCfg, jwks, token)
+	}
+
+	registerToolRoutes(mux, authCfg, jwks, token)
+
+	var realtime *realtimeProxy
+
 	if realtimeURL != nil {
-		mux.Handle("/api/v1/realtime", http.StripPrefix("/api", &httputil.ReverseProxy{
-			Rewrite: func(r *httputil.ProxyRequest) {
-				r.SetURL(realtimeURL)
+		realtime = newRealtimeProxy(realtimeURL, token, authCfg)
 
-				if token != "" {
-					r.Out.Header.Set("Authorization", "Bearer "+token)
-				}
-			},
-		}))
+		var realtimeHandler http.Handler = realtime
+
+		if authCfg.enabled {
+			realtimeHandler = authMiddleware(authCfg, jwks, token, realtimeHandler)
+		}
+
+		mux.Handle("/api/v1/realtime", realtimeHandler)
+
+		registerMetricsRoute(mux)
 	}
 
-	mux.Handle("/api/", http.StripPrefix("/api", &httputil.ReverseProxy{
+	var apiHandler http.Handler = http.StripPrefix("/api", &httputil.ReverseProxy{
 		Rewrite: func(r *httputil.ProxyRequest) {
 			r.SetURL(platformURL)
 
-			if token != "" {
+			if token != "" && (!authCfg.enabled || authCfg.mode != "passthrough") {
 				r.Out.Header.Set("Authorization", "Bearer "+token)
 			}
 		},
-	}))
+	})
+
+	if authCfg.enabled {
+		apiHandler = authMiddleware(authCfg, jwks, token, apiHandler)
+	}
+
+	mux.Handle("/api/", apiHandler)
+
+	srv := &http.Server{Addr: "0.0.0.0:8000", Handler: mux}
+
+	if realtime != nil {
+		go waitForDrain(srv, realtime, realtimeDrainTimeout())
+	}
 
-	http.ListenAndServe("0.0.0.0:8000", mux)
+	srv.ListenAndServe()
 
 }
 
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrMediaDisabled is returned by the null MediaStore when no backend has
+// been configured.
+var ErrMediaDisabled = errors.New("media storage is disabled")
+
+// MediaStore persists content-addressed blobs uploaded through
+// /api/v1/media. Implementations only need to honor the id handed back from
+// Put when serving Get.
+type MediaStore interface {
+	Put(ctx context.Context, contentType string, data []byte) (id string, err error)
+	Get(ctx context.Context, id string) (data []byte, contentType string, err error)
+}
+
+func mediaID(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// isMediaID reports whether id is a well-formed sha256 content address (64
+// lowercase hex characters). Every MediaStore joins id onto a base
+// directory or object key, so this must be checked before it ever reaches
+// a store implementation to rule out path traversal.
+func isMediaID(id string) bool {
+	if len(id) != 64 {
+		return false
+	}
+
+	for _, r := range id {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// nullMediaStore is used when no MEDIA_DIR or MEDIA_S3_* backend is
+// configured; every call fails with ErrMediaDisabled.
+type nullMediaStore struct{}
+
+func (nullMediaStore) Put(context.Context, string, []byte) (string, error) {
+	return "", ErrMediaDisabled
+}
+
+func (nullMediaStore) Get(context.Context, string) ([]byte, string, error) {
+	return nil, "", ErrMediaDisabled
+}
+
+// localMediaStore persists blobs as two files per id under dir: the raw
+// bytes, and a ".type" sidecar holding the MIME type.
+type localMediaStore struct {
+	dir string
+}
+
+func newLocalMediaStore(dir string) (*localMediaStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &localMediaStore{dir: dir}, nil
+}
+
+func (s *localMediaStore) Put(ctx context.Context, contentType string, data []byte) (string, error) {
+	id := mediaID(data)
+
+	if err := os.WriteFile(filepath.Join(s.dir, id), data, 0o644); err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(filepath.Join(s.dir, id+".type"), []byte(contentType), 0o644); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (s *localMediaStore) Get(ctx context.Context, id string) ([]byte, string, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, id))
+	if err != nil {
+		return nil, "", err
+	}
+
+	contentType, err := os.ReadFile(filepath.Join(s.dir, id+".type"))
+	if err != nil {
+		contentType = []byte("application/octet-stream")
+	}
+
+	return data, string(contentType), nil
+}
+
+// s3MediaStore stores blobs in an S3-compatible bucket, signing requests
+// with AWS Signature Version 4.
+type s3MediaStore struct {
+	endpoint  string
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+
+	client http.Client
+}
+
+func newS3MediaStore() *s3MediaStore {
+	region := os.Getenv("MEDIA_S3_REGION")
+
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &s3MediaStore{
+		endpoint:  strings.TrimRight(os.Getenv("MEDIA_S3_ENDPOINT"), "/"),
+		bucket:    os.Getenv("MEDIA_S3_BUCKET"),
+		region:    region,
+		accessKey: os.Getenv("MEDIA_S3_ACCESS_KEY"),
+		secretKey: os.Getenv("MEDIA_S3_SECRET_KEY"),
+	}
+}
+
+func (s *s3MediaStore) objectURL(id string) string {
+	return fmt.Sprintf("%s/%s/%s", s.endpoint, s.bucket, id)
+}
+
+func (s *s3MediaStore) Put(ctx context.Context, contentType string, data []byte) (string, error) {
+	id := mediaID(data)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(id), bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+
+	if err := signS3Request(req, data, s.region, s.accessKey, s.secretKey); err != nil {
+		return "", err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("s3 put failed: %s", resp.Status)
+	}
+
+	return id, nil
+}
+
+func (s *s3MediaStore) Get(ctx context.Context, id string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(id), nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := signS3Request(req, nil, s.region, s.accessKey, s.secretKey); err != nil {
+		return nil, "", err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", os.ErrNotExist
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, "", fmt.Errorf("s3 get failed: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// signS3Request adds AWS Signature Version 4 headers for the "s3" service.
+func signS3Request(req *http.Request, body []byte, region, accessKey, secretKey string) error {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders, canonicalHeaders := canonicalS3Headers(req)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.Path,
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", dateStamp, region)
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256(hmacSHA256(hmacSHA256(hmacSHA256([]byte("AWS4"+secretKey), dateStamp), region), "s3"), "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature,
+	))
+
+	return nil
+}
+
+func canonicalS3Headers(req *http.Request) (signedHeaders, canonicalHeaders string) {
+	names := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+
+	sort.Strings(names)
+
+	var canonical strings.Builder
+
+	for _, name := range names {
+		value := req.Header.Get(name)
+
+		if name == "host" {
+			value = req.URL.Host
+		}
+
+		fmt.Fprintf(&canonical, "%s:%s\n", name, strings.TrimSpace(value))
+	}
+
+	return strings.Join(names, ";"), canonical.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// mediaAllowedTypes returns the configured MIME allowlist, defaulting to the
+// formats the vision/image features already produce.
+func mediaAllowedTypes() []string {
+	val := os.Getenv("MEDIA_ALLOWED_TYPES")
+
+	if val == "" {
+		return []string{"image/png", "image/jpeg", "image/webp", "image/gif"}
+	}
+
+	var types []string
+
+	for _, t := range strings.Split(val, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+
+	return types
+}
+
+func mediaMaxSize() int64 {
+	val := os.Getenv("MEDIA_MAX_SIZE_BYTES")
+
+	if val == "" {
+		return 10 << 20 // 10MB
+	}
+
+	n, err := strconv.ParseInt(val, 10, 64)
+	if err != nil || n <= 0 {
+		return 10 << 20
+	}
+
+	return n
+}
+
+func mimeAllowed(allowed []string, contentType string) bool {
+	for _, t := range allowed {
+		if t == contentType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newMediaStore builds the MediaStore selected by the MEDIA_* environment
+// variables, or a disabled null store when none are set.
+func newMediaStore() (MediaStore, bool) {
+	if dir := os.Getenv("MEDIA_DIR"); dir != "" {
+		store, err := newLocalMediaStore(dir)
+		if err != nil {
+			return nullMediaStore{}, false
+		}
+
+		return store, true
+	}
+
+	if os.Getenv("MEDIA_S3_BUCKET") != "" {
+		return newS3MediaStore(), true
+	}
+
+	return nullMediaStore{}, false
+}
+
+func registerMediaRoutes(mux *http.ServeMux, store MediaStore, enabled bool) {
+	allowed := mediaAllowedTypes()
+	maxSize := mediaMaxSize()
+
+	mux.HandleFunc("POST /api/v1/media", func(w http.ResponseWriter, r *http.Request) {
+		if !enabled {
+			http.NotFound(w, r)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+
+		if err := r.ParseMultipartForm(maxSize); err != nil {
+			http.Error(w, "upload too large or malformed: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "missing file field", http.StatusBadRequest)
+			return
+		}
+
+		defer file.Close()
+
+		contentType := header.Header.Get("Content-Type")
+
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		if !mimeAllowed(allowed, contentType) {
+			http.Error(w, fmt.Sprintf("content type %q is not allowed", contentType), http.StatusUnsupportedMediaType)
+			return
+		}
+
+		data, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		id, err := store.Put(r.Context(), contentType, data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":%q,"url":"/api/v1/media/%s"}`, id, id)
+	})
+
+	mux.HandleFunc("GET /api/v1/media/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !enabled {
+			http.NotFound(w, r)
+			return
+		}
+
+		id := r.PathValue("id")
+
+		if !isMediaID(id) {
+			http.NotFound(w, r)
+			return
+		}
+
+		data, contentType, err := store.Get(r.Context(), id)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Write(data)
+	})
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestIsMediaID(t *testing.T) {
+	valid := mediaID([]byte("hello"))
+
+	cases := []struct {
+		id string
+		ok bool
+	}{
+		{valid, true},
+		{"../secret.txt", false},
+		{"..%2fsecret.txt", false},
+		{"", false},
+		{valid[:63], false},
+		{valid + "a", false},
+		{"ZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZ", false},
+	}
+
+	for _, c := range cases {
+		if got := isMediaID(c.id); got != c.ok {
+			t.Errorf("isMediaID(%q) = %v, want %v", c.id, got, c.ok)
+		}
+	}
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// realtimeMetrics holds the counters served at /metrics in Prometheus text
+// format. All fields are updated with atomic operations so the proxy loop
+// never needs to take a lock on the hot path.
+type realtimeMetrics struct {
+	sessionsTotal  uint64
+	sessionsActive int64
+	bytesIn        uint64
+	bytesOut       uint64
+	dialErrors     uint64
+
+	durationSeconds uint64 // sum of closed session durations, in whole seconds
+	durationCount   uint64
+}
+
+var metrics realtimeMetrics
+
+func (m *realtimeMetrics) sessionStarted() {
+	atomic.AddUint64(&m.sessionsTotal, 1)
+	atomic.AddInt64(&m.sessionsActive, 1)
+}
+
+func (m *realtimeMetrics) sessionEnded(d time.Duration) {
+	atomic.AddInt64(&m.sessionsActive, -1)
+	atomic.AddUint64(&m.durationSeconds, uint64(d.Seconds()))
+	atomic.AddUint64(&m.durationCount, 1)
+}
+
+func registerMetricsRoute(mux *http.ServeMux) {
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		fmt.Fprintf(w, "# HELP wingman_realtime_sessions_total Total realtime sessions accepted.\n")
+		fmt.Fprintf(w, "# TYPE wingman_realtime_sessions_total counter\n")
+		fmt.Fprintf(w, "wingman_realtime_sessions_total %d\n", atomic.LoadUint64(&metrics.sessionsTotal))
+
+		fmt.Fprintf(w, "# HELP wingman_realtime_sessions_active Realtime sessions currently open.\n")
+		fmt.Fprintf(w, "# TYPE wingman_realtime_sessions_active gauge\n")
+		fmt.Fprintf(w, "wingman_realtime_sessions_active %d\n", atomic.LoadInt64(&metrics.sessionsActive))
+
+		fmt.Fprintf(w, "# HELP wingman_realtime_bytes_in_total Bytes received from clients.\n")
+		fmt.Fprintf(w, "# TYPE wingman_realtime_bytes_in_total counter\n")
+		fmt.Fprintf(w, "wingman_realtime_bytes_in_total %d\n", atomic.LoadUint64(&metrics.bytesIn))
+
+		fmt.Fprintf(w, "# HELP wingman_realtime_bytes_out_total Bytes sent to clients.\n")
+		fmt.Fprintf(w, "# TYPE wingman_realtime_bytes_out_total counter\n")
+		fmt.Fprintf(w, "wingman_realtime_bytes_out_total %d\n", atomic.LoadUint64(&metrics.bytesOut))
+
+		fmt.Fprintf(w, "# HELP wingman_realtime_dial_errors_total Upstream dial failures.\n")
+		fmt.Fprintf(w, "# TYPE wingman_realtime_dial_errors_total counter\n")
+		fmt.Fprintf(w, "wingman_realtime_dial_errors_total %d\n", atomic.LoadUint64(&metrics.dialErrors))
+
+		fmt.Fprintf(w, "# HELP wingman_realtime_session_duration_seconds_sum Sum of closed session durations.\n")
+		fmt.Fprintf(w, "# TYPE wingman_realtime_session_duration_seconds_sum counter\n")
+		fmt.Fprintf(w, "wingman_realtime_session_duration_seconds_sum %d\n", atomic.LoadUint64(&metrics.durationSeconds))
+
+		fmt.Fprintf(w, "# HELP wingman_realtime_session_duration_seconds_count Count of closed sessions.\n")
+		fmt.Fprintf(w, "# TYPE wingman_realtime_session_duration_seconds_count counter\n")
+		fmt.Fprintf(w, "wingman_realtime_session_duration_seconds_count %d\n", atomic.LoadUint64(&metrics.durationCount))
+	})
+}
+
+// sessionLimiter enforces the global and per-IP concurrent session caps.
+type sessionLimiter struct {
+	maxGlobal int
+	maxPerIP  int
+
+	global int64
+
+	mu    sync.Mutex
+	perIP map[string]int
+}
+
+func newSessionLimiter(maxGlobal, maxPerIP int) *sessionLimiter {
+	return &sessionLimiter{
+		maxGlobal: maxGlobal,
+		maxPerIP:  maxPerIP,
+		perIP:     map[string]int{},
+	}
+}
+
+func (l *sessionLimiter) acquire(ip string) (release func(), ok bool) {
+	l.mu.Lock()
+
+	if l.maxGlobal > 0 && atomic.LoadInt64(&l.global) >= int64(l.maxGlobal) {
+		l.mu.Unlock()
+		return nil, false
+	}
+
+	if l.maxPerIP > 0 && l.perIP[ip] >= l.maxPerIP {
+		l.mu.Unlock()
+		return nil, false
+	}
+
+	l.perIP[ip]++
+	atomic.AddInt64(&l.global, 1)
+	l.mu.Unlock()
+
+	return func() {
+		atomic.AddInt64(&l.global, -1)
+
+		l.mu.Lock()
+		l.perIP[ip]--
+		if l.perIP[ip] <= 0 {
+			delete(l.perIP, ip)
+		}
+		l.mu.Unlock()
+	}, true
+}
+
+func realtimeMaxSessions() int {
+	return envInt("REALTIME_MAX_SESSIONS", 0)
+}
+
+func realtimeMaxPerIP() int {
+	return envInt("REALTIME_MAX_PER_IP", 0)
+}
+
+func realtimeDrainTimeout() time.Duration {
+	return time.Duration(envInt("REALTIME_DRAIN_TIMEOUT", 30)) * time.Second
+}
+
+func envInt(name string, fallback int) int {
+	val := os.Getenv(name)
+
+	if val == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		return fallback
+	}
+
+	return n
+}
+
+// realtimeProxy is a dedicated WebSocket-aware handler for /api/v1/realtime.
+// Unlike httputil.ReverseProxy it terminates the WebSocket handshake itself,
+// dials the upstream directly, and tracks session limits and metrics.
+type realtimeProxy struct {
+	upstream *url.URL
+	token    string
+	auth     authConfig
+
+	limiter *sessionLimiter
+
+	wg       sync.WaitGroup
+	draining atomic.Bool
+}
+
+func newRealtimeProxy(upstream *url.URL, token string, auth authConfig) *realtimeProxy {
+	return &realtimeProxy{
+		upstream: upstream,
+		token:    token,
+		auth:     auth,
+		limiter:  newSessionLimiter(realtimeMaxSessions(), realtimeMaxPerIP()),
+	}
+}
+
+// drain stops accepting new sessions and waits up to timeout for existing
+// sessions to finish on their own.
+func (p *realtimeProxy) drain(timeout time.Duration) {
+	p.draining.Store(true)
+
+	done := make(chan struct{})
+
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+// authHeader returns the Authorization header to present to the upstream:
+// the client's own token in passthrough mode, otherwise the server's
+// platform token.
+func (p *realtimeProxy) authHeader(r *http.Request) string {
+	if p.auth.enabled && p.auth.mode == "passthrough" {
+		return r.Header.Get("Authorization")
+	}
+
+	if p.token == "" {
+		return ""
+	}
+
+	return "Bearer " + p.token
+}
+
+func (p *realtimeProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p.draining.Load() {
+		http.Error(w, "server is draining", http.StatusServiceUnavailable)
+		return
+	}
+
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+	if ip == "" {
+		ip = r.RemoteAddr
+	}
+
+	release, ok := p.limiter.acquire(ip)
+	if !ok {
+		http.Error(w, "too many realtime sessions", http.StatusTooManyRequests)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		release()
+		http.Error(w, "websocket upgrade unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		release()
+		http.Error(w, "not a websocket upgrade request", http.StatusBadRequest)
+		return
+	}
+
+	upstreamConn, err := dialUpstream(p.upstream, r, p.authHeader(r))
+	if err != nil {
+		release()
+		atomic.AddUint64(&metrics.dialErrors, 1)
+		http.Error(w, "upstream unavailable: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	clientConn, clientBuf, err := hijacker.Hijack()
+	if err != nil {
+		release()
+		upstreamConn.Close()
+		http.Error(w, "hijack failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintf(clientConn, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", webSocketAccept(key))
+
+	if clientBuf.Reader.Buffered() > 0 {
+		buffered, _ := clientBuf.Reader.Peek(clientBuf.Reader.Buffered())
+		upstreamConn.Write(buffered)
+	}
+
+	p.wg.Add(1)
+	metrics.sessionStarted()
+
+	start := time.Now()
+
+	go func() {
+		defer p.wg.Done()
+		defer release()
+		defer clientConn.Close()
+		defer upstreamConn.Close()
+
+		pumpWebsocket(clientConn, upstreamConn)
+
+		metrics.sessionEnded(time.Since(start))
+	}()
+}
+
+// dialUpstream opens a raw connection to the realtime upstream and replays
+// the original upgrade handshake so the upstream sees a normal WebSocket
+// client request.
+func dialUpstream(upstream *url.URL, r *http.Request, authHeader string) (net.Conn, error) {
+	secure := upstream.Scheme == "https" || upstream.Scheme == "wss"
+
+	host := upstream.Host
+	if !strings.Contains(host, ":") {
+		if secure {
+			host += ":443"
+		} else {
+			host += ":80"
+		}
+	}
+
+	dialer := net.Dialer{Timeout: 5 * time.Second}
+
+	var conn net.Conn
+	var err error
+
+	if secure {
+		conn, err = tls.DialWithDialer(&dialer, "tcp", host, &tls.Config{ServerName: upstream.Hostname()})
+	} else {
+		conn, err = dialer.Dial("tcp", host)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	path := strings.TrimSuffix(upstream.Path, "/") + strings.TrimPrefix(r.URL.Path, "/api")
+	if r.URL.RawQuery != "" {
+		path += "?" + r.URL.RawQuery
+	}
+
+	var req strings.Builder
+
+	fmt.Fprintf(&req, "GET %s HTTP/1.1\r\n", path)
+	fmt.Fprintf(&req, "Host: %s\r\n", upstream.Host)
+	req.WriteString("Upgrade: websocket\r\n")
+	req.WriteString("Connection: Upgrade\r\n")
+	fmt.Fprintf(&req, "Sec-WebSocket-Key: %s\r\n", r.Header.Get("Sec-WebSocket-Key"))
+	fmt.Fprintf(&req, "Sec-WebSocket-Version: %s\r\n", r.Header.Get("Sec-WebSocket-Version"))
+
+	if protocol := r.Header.Get("Sec-WebSocket-Protocol"); protocol != "" {
+		fmt.Fprintf(&req, "Sec-WebSocket-Protocol: %s\r\n", protocol)
+	}
+
+	if authHeader != "" {
+		fmt.Fprintf(&req, "Authorization: %s\r\n", authHeader)
+	}
+
+	req.WriteString("\r\n")
+
+	if _, err := conn.Write([]byte(req.String())); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	upstreamBuf := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(upstreamBuf, nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("upstream refused upgrade: %s", resp.Status)
+	}
+
+	// http.ReadResponse may have buffered upstream bytes sent in the same
+	// segment as the 101 response (the first WS frame, commonly). Route
+	// reads through that buffer first so none of it is lost once the
+	// caller switches to raw conn reads/writes.
+	return &bufferedConn{Conn: conn, r: upstreamBuf}, nil
+}
+
+// bufferedConn is a net.Conn whose reads are served from r first, falling
+// through to the underlying Conn once r's buffer is drained. It lets code
+// that parsed a headered preamble with a bufio.Reader hand back a plain
+// net.Conn without losing whatever the reader had already buffered.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+const websocketMagic = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+func webSocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketMagic))
+
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// waitForDrain blocks until SIGTERM, then stops the realtime proxy from
+// accepting new sessions, waits up to timeout for in-flight sessions to
+// finish, and shuts the HTTP server down.
+func waitForDrain(srv *http.Server, realtime *realtimeProxy, timeout time.Duration) {
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGTERM)
+	<-sigc
+
+	realtime.drain(timeout)
+	srv.Shutdown(context.Background())
+}
+
+// pumpWebsocket relays raw bytes between the client and upstream connections
+// until either side closes, counting bytes for the /metrics endpoint.
+func pumpWebsocket(client, upstream net.Conn) {
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		defer upstream.Close()
+
+		n, _ := io.Copy(upstream, client)
+		atomic.AddUint64(&metrics.bytesIn, uint64(n))
+	}()
+
+	go func() {
+		defer wg.Done()
+		defer client.Close()
+
+		n, _ := io.Copy(client, upstream)
+		atomic.AddUint64(&metrics.bytesOut, uint64(n))
+	}()
+
+	wg.Wait()
+}
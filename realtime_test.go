@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestWebSocketAccept(t *testing.T) {
+	// Example from RFC 6455 section 1.3.
+	got := webSocketAccept("dGhlIHNhbXBsZSBub25jZQ==")
+	want := "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="
+
+	if got != want {
+		t.Errorf("webSocketAccept() = %q, want %q", got, want)
+	}
+}
+
+func TestSessionLimiterCaps(t *testing.T) {
+	l := newSessionLimiter(1, 1)
+
+	release, ok := l.acquire("1.2.3.4")
+	if !ok {
+		t.Fatal("expected first acquire to succeed")
+	}
+
+	if _, ok := l.acquire("1.2.3.4"); ok {
+		t.Fatal("expected second acquire from same IP to be capped")
+	}
+
+	release()
+
+	if _, ok := l.acquire("1.2.3.4"); !ok {
+		t.Fatal("expected acquire to succeed again after release")
+	}
+}
+
+// TestDialUpstreamPreservesBufferedBytes reproduces an upstream that writes
+// its first WebSocket frame in the same segment as the 101 response. Bytes
+// buffered while parsing that response must still be readable from the
+// returned conn, not silently dropped.
+func TestDialUpstreamPreservesBufferedBytes(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	const payload = "HELLO-FRAME-DATA"
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		buf := make([]byte, 4096)
+		conn.Read(buf)
+
+		conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n" +
+			"Upgrade: websocket\r\n" +
+			"Connection: Upgrade\r\n\r\n" + payload))
+	}()
+
+	upstream := &url.URL{Scheme: "ws", Host: ln.Addr().String()}
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/realtime", nil)
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+
+	conn, err := dialUpstream(upstream, req, "")
+	if err != nil {
+		t.Fatalf("dialUpstream: %v", err)
+	}
+	defer conn.Close()
+
+	got := make([]byte, len(payload))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatalf("reading buffered upstream bytes: %v", err)
+	}
+
+	if string(got) != payload {
+		t.Errorf("got %q, want %q", got, payload)
+	}
+}